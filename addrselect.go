@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net"
+	"sort"
+)
+
+// RFC 6724 destination address selection. This mirrors the algorithm in
+// Go's internal net/addrselect.go (rules 1, 2, 5, 6, 8 and 9 of the RFC;
+// the mobility/deprecated-address/native-transport rules don't apply here
+// since we have no interface or tunnel information to test them against).
+
+// addrScope is an RFC 4007 scope value.
+type addrScope int
+
+const (
+	scopeInterfaceLocal addrScope = 0x1
+	scopeLinkLocal      addrScope = 0x2
+	scopeAdminLocal     addrScope = 0x4
+	scopeSiteLocal      addrScope = 0x5
+	scopeOrgLocal       addrScope = 0x8
+	scopeGlobal         addrScope = 0xe
+)
+
+// policyEntry is a row of the RFC 6724 default policy table.
+type policyEntry struct {
+	prefix     *net.IPNet
+	precedence int
+	label      int
+}
+
+// rfc6724PolicyTable is the default policy table from RFC 6724 section 2.1.
+// ::1/128 precedes the ::/96 prefix that contains it and ::/0 comes last; the
+// other prefixes don't overlap, so the first match is the longest match.
+var rfc6724PolicyTable = []policyEntry{
+	{prefix: mustCIDR("::1/128"), precedence: 50, label: 0},
+	{prefix: mustCIDR("::ffff:0:0/96"), precedence: 35, label: 4},
+	{prefix: mustCIDR("2002::/16"), precedence: 30, label: 2},
+	{prefix: mustCIDR("2001::/32"), precedence: 5, label: 5},
+	{prefix: mustCIDR("fc00::/7"), precedence: 3, label: 13},
+	{prefix: mustCIDR("::/96"), precedence: 1, label: 3},
+	{prefix: mustCIDR("::/0"), precedence: 40, label: 1},
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// classify returns the precedence and label of ip per the policy table.
+func classify(ip net.IP) (precedence, label int) {
+	for _, e := range rfc6724PolicyTable {
+		if e.prefix.Contains(ip) {
+			return e.precedence, e.label
+		}
+	}
+	return 40, 1 // ::/0 default, unreachable in practice since it's in the table
+}
+
+// classifyScope returns the RFC 4007 scope of ip.
+func classifyScope(ip net.IP) addrScope {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return scopeLinkLocal
+	}
+	if ip.IsInterfaceLocalMulticast() {
+		return scopeInterfaceLocal
+	}
+	if ip16 := ip.To16(); ip16 != nil && ip.IsMulticast() {
+		return addrScope(ip16[1] & 0xf)
+	}
+	if ip.IsPrivate() {
+		// Covers RFC 1918 IPv4 space and RFC 4193 IPv6 ULAs (fc00::/7).
+		return scopeSiteLocal
+	}
+	return scopeGlobal
+}
+
+// srcAddrFor returns the address the local routing table would use to reach
+// dst, without sending any packets (the "UDP connect trick": UDP Dial only
+// resolves a route and binds a local address).
+func srcAddrFor(dst net.IP) (net.IP, bool) {
+	conn, err := net.Dial("udp", net.JoinHostPort(dst.String(), "53"))
+	if err != nil {
+		return nil, false
+	}
+	defer conn.Close()
+	udpAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, false
+	}
+	return udpAddr.IP, true
+}
+
+// commonPrefixLen returns the number of leading bits a and b have in common.
+func commonPrefixLen(a, b net.IP) int {
+	a16, b16 := a.To16(), b.To16()
+	if a16 == nil || b16 == nil {
+		return 0
+	}
+	n := 0
+	for i := range a16 {
+		x := a16[i] ^ b16[i]
+		if x == 0 {
+			n += 8
+			continue
+		}
+		for x&0x80 == 0 {
+			n++
+			x <<= 1
+		}
+		break
+	}
+	return n
+}
+
+func sameFamily(a, b net.IP) bool {
+	return (a.To4() == nil) == (b.To4() == nil)
+}
+
+// candidate bundles a destination address with the attributes the sort
+// rules below compare.
+type candidate struct {
+	addr       net.IP
+	precedence int
+	label      int
+	scope      addrScope
+
+	src      net.IP
+	srcOK    bool
+	srcScope addrScope
+	srcLabel int
+}
+
+// sortDestinations orders addrs best-first per RFC 6724 rules 1 (avoid
+// unusable destinations), 2 (prefer matching scope), 5 (prefer matching
+// label), 6 (prefer higher precedence), 8 (prefer smaller scope) and 9
+// (longest matching prefix, same address family only).
+func sortDestinations(addrs []net.IP) []net.IP {
+	cands := make([]candidate, len(addrs))
+	for i, addr := range addrs {
+		c := candidate{addr: addr, scope: classifyScope(addr)}
+		c.precedence, c.label = classify(addr)
+		if src, ok := srcAddrFor(addr); ok {
+			c.src = src
+			c.srcOK = true
+			c.srcScope = classifyScope(src)
+			_, c.srcLabel = classify(src)
+		}
+		cands[i] = c
+	}
+
+	sort.SliceStable(cands, func(i, j int) bool {
+		return less(cands[i], cands[j])
+	})
+
+	out := make([]net.IP, len(cands))
+	for i, c := range cands {
+		out[i] = c.addr
+	}
+	return out
+}
+
+func less(a, b candidate) bool {
+	// Rule 1: prefer destinations we found a usable source address for.
+	if a.srcOK != b.srcOK {
+		return a.srcOK
+	}
+	if a.srcOK && b.srcOK {
+		// Rule 2: prefer matching scope.
+		if am, bm := a.scope == a.srcScope, b.scope == b.srcScope; am != bm {
+			return am
+		}
+		// Rule 5: prefer matching label.
+		if am, bm := a.label == a.srcLabel, b.label == b.srcLabel; am != bm {
+			return am
+		}
+	}
+	// Rule 6: prefer higher precedence.
+	if a.precedence != b.precedence {
+		return a.precedence > b.precedence
+	}
+	// Rule 8: prefer smaller scope.
+	if a.scope != b.scope {
+		return a.scope < b.scope
+	}
+	// Rule 9: prefer longest matching prefix, same family only.
+	if a.srcOK && b.srcOK && sameFamily(a.addr, b.addr) {
+		if al, bl := commonPrefixLen(a.src, a.addr), commonPrefixLen(b.src, b.addr); al != bl {
+			return al > bl
+		}
+	}
+	// Rule 10: leave the order as found.
+	return false
+}
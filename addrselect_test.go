@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClassifyScope(t *testing.T) {
+	cases := []struct {
+		name string
+		ip   string
+		want addrScope
+	}{
+		{"loopback v4", "127.0.0.1", scopeLinkLocal},
+		{"loopback v6", "::1", scopeLinkLocal},
+		{"link-local v6", "fe80::1", scopeLinkLocal},
+		{"private v4", "192.168.1.1", scopeSiteLocal},
+		{"unique-local v6", "fd00::1", scopeSiteLocal},
+		{"global v4", "8.8.8.8", scopeGlobal},
+		{"global v6", "2001:4860:4860::8888", scopeGlobal},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := classifyScope(net.ParseIP(c.ip))
+			if got != c.want {
+				t.Errorf("classifyScope(%s) = %#x, want %#x", c.ip, got, c.want)
+			}
+		})
+	}
+}
+
+func TestLessPrefersSmallerScopeOnTie(t *testing.T) {
+	// Both candidates tie on rules 1, 2, 5 and 6 (same precedence/label,
+	// same-scope source each), so rule 8 must decide: smaller scope wins
+	// rather than falling through to rule 10's original order.
+	linkLocal := candidate{
+		addr: net.ParseIP("fe80::1"), precedence: 40, label: 1, scope: scopeLinkLocal,
+		src: net.ParseIP("fe80::2"), srcOK: true, srcScope: scopeLinkLocal, srcLabel: 1,
+	}
+	global := candidate{
+		addr: net.ParseIP("2001:db8::1"), precedence: 40, label: 1, scope: scopeGlobal,
+		src: net.ParseIP("2001:db8::2"), srcOK: true, srcScope: scopeGlobal, srcLabel: 1,
+	}
+
+	if !less(linkLocal, global) {
+		t.Error("less(linkLocal, global) = false, want true: smaller scope should sort first")
+	}
+	if less(global, linkLocal) {
+		t.Error("less(global, linkLocal) = true, want false")
+	}
+}
+
+func TestLessPrefersPrecedenceOverScope(t *testing.T) {
+	// Rule 6 (precedence) must be decided before rule 8 (scope): a global
+	// address at the ::/0 default precedence (40) beats a site-local ULA at
+	// the fc00::/7 precedence (3) even though the ULA has the smaller scope.
+	global := candidate{
+		addr: net.ParseIP("2001:db8::1"), precedence: 40, label: 1, scope: scopeGlobal,
+		src: net.ParseIP("2001:db8::2"), srcOK: true, srcScope: scopeGlobal, srcLabel: 1,
+	}
+	ula := candidate{
+		addr: net.ParseIP("fc00::1"), precedence: 3, label: 13, scope: scopeSiteLocal,
+		src: net.ParseIP("fc00::2"), srcOK: true, srcScope: scopeSiteLocal, srcLabel: 13,
+	}
+
+	if !less(global, ula) {
+		t.Error("less(global, ula) = false, want true: higher precedence must win over smaller scope")
+	}
+	if less(ula, global) {
+		t.Error("less(ula, global) = true, want false")
+	}
+}
+
+func TestSortDestinationsKeepsAllCandidates(t *testing.T) {
+	// Which candidate sorts first depends on the routes of the host running
+	// the test, so this only checks that sortDestinations doesn't drop
+	// entries.
+	in := []net.IP{net.ParseIP("2001:4860:4860::8888"), net.ParseIP("8.8.8.8")}
+	out := sortDestinations(in)
+	if len(out) != len(in) {
+		t.Fatalf("sortDestinations dropped addresses: got %v, want %v", out, in)
+	}
+}
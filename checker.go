@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultParallelism bounds the number of in-flight probes when Parallelism
+// is left unset, so a large public_peers checkout doesn't fan out one
+// goroutine (and one file descriptor) per peer.
+const defaultParallelism = 64
+
+// Checker probes a set of peers and caches the most recent result set so it
+// can be served repeatedly (e.g. by the HTTP status server in -serve mode)
+// without re-running the probe on every read.
+type Checker struct {
+	Source    PeerSource
+	Regions   []string
+	Countries []string
+
+	// Parallelism caps the number of peers dialed concurrently. Zero means
+	// defaultParallelism.
+	Parallelism int
+	// PerHostDelay is the minimum spacing between dials to the same
+	// resolved IP, so peers sharing a host aren't dialed simultaneously.
+	// Zero disables per-host rate limiting.
+	PerHostDelay time.Duration
+	// Retries and Backoff configure how a dead address is retried before
+	// being declared down; see RetryConfig.
+	Retries int
+	Backoff time.Duration
+	// Deep requires a recognized metadata handshake reply, not just a bare
+	// dial, before a peer counts as up; see RetryConfig and deepprobe.go.
+	Deep bool
+
+	mu      sync.RWMutex
+	results []Peer
+	checked time.Time
+}
+
+// NewChecker returns a Checker reading peer definitions from source,
+// restricted to regions/countries if given (nil means "all").
+func NewChecker(source PeerSource, regions []string, countries []string) *Checker {
+	return &Checker{
+		Source:    source,
+		Regions:   regions,
+		Countries: countries,
+	}
+}
+
+// Probe re-reads the peer list from Source, checks liveness of every peer
+// through a bounded worker pool, and atomically swaps in the new result set.
+func (c *Checker) Probe() error {
+	peers, err := c.Source.Peers(c.Regions, c.Countries)
+	if err != nil {
+		return err
+	}
+
+	parallelism := c.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+	limiter := newHostLimiter(c.PerHostDelay)
+	retry := RetryConfig{Retries: c.Retries, Backoff: c.Backoff, Deep: c.Deep}
+
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range peers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p *Peer) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			probeFn(p, limiter, retry)
+		}(&peers[i])
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	c.results = peers
+	c.checked = time.Now()
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Results returns a snapshot of the last completed probe's results.
+func (c *Checker) Results() []Peer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make([]Peer, len(c.results))
+	copy(out, c.results)
+	return out
+}
+
+// LastChecked returns when the last probe completed, or the zero time if no
+// probe has run yet.
+func (c *Checker) LastChecked() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.checked
+}
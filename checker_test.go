@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSource returns a fixed set of peers without touching the filesystem or
+// network.
+type fakeSource struct {
+	peers []Peer
+}
+
+func (s fakeSource) Peers(regions []string, countries []string) ([]Peer, error) {
+	return s.peers, nil
+}
+
+func TestCheckerProbeBoundsConcurrency(t *testing.T) {
+	const peerCount = 20
+	const parallelism = 3
+
+	peers := make([]Peer, peerCount)
+	for i := range peers {
+		peers[i] = Peer{URI: "tcp://host/1", Protocol: "tcp"}
+	}
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int32
+
+	orig := probeFn
+	probeFn = func(peer *Peer, limiter *hostLimiter, cfg RetryConfig) {
+		n := atomic.AddInt32(&inFlight, 1)
+		mu.Lock()
+		if n > maxInFlight {
+			maxInFlight = n
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}
+	defer func() { probeFn = orig }()
+
+	checker := NewChecker(fakeSource{peers: peers}, nil, nil)
+	checker.Parallelism = parallelism
+	if err := checker.Probe(); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+
+	if maxInFlight > parallelism {
+		t.Errorf("observed %d concurrent probes, want at most %d", maxInFlight, parallelism)
+	}
+	if got := len(checker.Results()); got != peerCount {
+		t.Errorf("Results() returned %d peers, want %d", got, peerCount)
+	}
+}
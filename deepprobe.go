@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// deepHandshake attempts the Yggdrasil peer-link metadata exchange over an
+// already-established connection: we send a fixed-size "meta" message
+// announcing our version and (optionally) a key, then read the same shape
+// back.
+//
+// CAVEAT: this wire layout is reconstructed from memory of the public
+// yggdrasil-go source; this sandbox has neither network access nor a
+// vendored copy of that repository to check it against byte-for-byte, so
+// treat it as a best-effort approximation pending verification against the
+// real source before depending on it for actual interop. What IS verified
+// here is the behavior the request asked for: a reply that doesn't match
+// this shape is distinguished from one that does (see errUnrecognizedReply
+// and classifyError's FailureDeepUnverified), rather than being silently
+// treated as equivalent to a confirmed-alive peer.
+//
+// Message layout (9 + keySize bytes):
+//
+//	4 bytes  magic "meta"
+//	2 bytes  major version (big-endian)
+//	2 bytes  minor version (big-endian)
+//	1 byte   key length N
+//	N bytes  public key
+const metaMagic = "meta"
+
+// errUnrecognizedReply marks a deepHandshake failure as "the peer didn't
+// speak our metadata format" (wrong magic, or no reply at all before the
+// deadline) as opposed to a plain I/O error, so classifyError can report it
+// distinctly from a dial failure.
+var errUnrecognizedReply = errors.New("peer did not reply with a recognized metadata handshake")
+
+// ourMetaVersion is the protocol version peer_checker announces itself as
+// during the handshake; it doesn't need to match any real Yggdrasil release
+// since we only care about the peer's reply.
+var ourMetaVersion = [2]byte{0, 4}
+
+// deadlineSetter is implemented by both net.Conn and quic.Stream; without a
+// deadline, a peer that accepts the connection but never replies to the
+// handshake would hang the probe forever.
+type deadlineSetter interface {
+	SetDeadline(t time.Time) error
+}
+
+func deepHandshake(peer *Peer, rw io.ReadWriter) error {
+	if ds, ok := rw.(deadlineSetter); ok {
+		if err := ds.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+			return fmt.Errorf("setting handshake deadline: %w", err)
+		}
+	}
+
+	out := make([]byte, 0, 9)
+	out = append(out, metaMagic...)
+	out = append(out, ourMetaVersion[0], ourMetaVersion[1])
+	out = append(out, 0, 0) // minor version, unused
+	out = append(out, 0)    // we present no key of our own
+	if _, err := rw.Write(out); err != nil {
+		return fmt.Errorf("writing metadata handshake: %w", err)
+	}
+
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(rw, header); err != nil {
+		return fmt.Errorf("reading metadata handshake: %w: %w", errUnrecognizedReply, err)
+	}
+	if string(header[:4]) != metaMagic {
+		return fmt.Errorf("%w: unexpected metadata magic %q", errUnrecognizedReply, header[:4])
+	}
+
+	major, minor := header[4:6], header[6:8]
+	keyLen := int(header[8])
+	key := make([]byte, keyLen)
+	if keyLen > 0 {
+		if _, err := io.ReadFull(rw, key); err != nil {
+			return fmt.Errorf("reading metadata handshake key: %w", err)
+		}
+	}
+
+	peer.NodeVersion = fmt.Sprintf("%d.%d", beUint16(major), beUint16(minor))
+	peer.NodeKey = hex.EncodeToString(key)
+	return nil
+}
+
+func beUint16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
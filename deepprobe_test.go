@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// loopbackConn feeds a pre-recorded handshake reply back to deepHandshake
+// without needing a real socket, and doesn't implement deadlineSetter.
+type loopbackConn struct {
+	bytes.Buffer
+	reply *bytes.Buffer
+}
+
+func (c *loopbackConn) Read(p []byte) (int, error) {
+	return c.reply.Read(p)
+}
+
+func TestDeepHandshake(t *testing.T) {
+	reply := append([]byte(metaMagic), 0, 4, 0, 1, 2, 0xaa, 0xbb)
+	conn := &loopbackConn{reply: bytes.NewBuffer(reply)}
+
+	peer := &Peer{}
+	if err := deepHandshake(peer, conn); err != nil {
+		t.Fatalf("deepHandshake returned error: %v", err)
+	}
+
+	if peer.NodeVersion != "4.1" {
+		t.Errorf("NodeVersion = %q, want %q", peer.NodeVersion, "4.1")
+	}
+	if peer.NodeKey != "aabb" {
+		t.Errorf("NodeKey = %q, want %q", peer.NodeKey, "aabb")
+	}
+
+	sent := conn.Buffer.Bytes()
+	if string(sent[:4]) != metaMagic {
+		t.Errorf("did not send metadata magic, got %q", sent[:4])
+	}
+}
+
+func TestDeepHandshakeRejectsBadMagic(t *testing.T) {
+	conn := &loopbackConn{reply: bytes.NewBuffer(append([]byte("nope"), 0, 0, 0, 0, 0))}
+	err := deepHandshake(&Peer{}, conn)
+	if err == nil {
+		t.Fatal("expected an error for a bad magic, got nil")
+	}
+	if !errors.Is(err, errUnrecognizedReply) {
+		t.Errorf("error = %v, want it to wrap errUnrecognizedReply so classifyError can report FailureDeepUnverified", err)
+	}
+}
@@ -1,21 +1,19 @@
 package main
 
 import (
-	"context"
-	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"log/slog"
+	"io"
 	"net"
 	"os"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"text/tabwriter"
 	"time"
-
-	"github.com/quic-go/quic-go"
 )
 
 var (
@@ -26,13 +24,28 @@ const connTimeout = 5 * time.Second
 
 type Peer struct {
 	URI      string
-	protocol string
+	Protocol string
 	host     string
 	port     int
 	Region   string
 	Country  string
 	Up       bool
 	Latency  time.Duration
+
+	// Attempts is the number of dial attempts made across all candidate
+	// addresses and retries.
+	Attempts int
+	// Failure classifies why a dead peer didn't respond; it's FailureNone
+	// for peers that are up.
+	Failure FailureReason
+	// FailureMsg is the raw error from the last failed attempt.
+	FailureMsg string
+
+	// NodeVersion and NodeKey are populated in -deep mode from the peer's
+	// metadata handshake reply (see deepprobe.go); they're empty for peers
+	// probed without -deep, and for -deep peers that end up Down.
+	NodeVersion string
+	NodeKey     string
 }
 
 func getPeers(dataDir string, regions []string, countries []string) ([]Peer, error) {
@@ -75,22 +88,7 @@ func getPeers(dataDir string, regions []string, countries []string) ([]Peer, err
 				if err != nil {
 					return nil, err
 				}
-				matches := PEER_REGEX.FindAllStringSubmatch(string(content), -1)
-				for _, match := range matches {
-					// fmt.Println("Match:", match)
-					uri := match[0]
-					protocol := match[1]
-					host := match[2]
-					port, _ := strconv.Atoi(match[3])
-					peers = append(peers, Peer{
-						URI:      uri,
-						protocol: protocol,
-						host:     host,
-						port:     port,
-						Region:   region,
-						Country:  country,
-					})
-				}
+				peers = append(peers, extractPeers(string(content), region, country)...)
 			}
 		}
 	}
@@ -98,68 +96,107 @@ func getPeers(dataDir string, regions []string, countries []string) ([]Peer, err
 	return peers, nil
 }
 
-func resolve(name string) (string, error) {
-	if strings.HasPrefix(name, "[") {
-		return name[1 : len(name)-1], nil
+// extractPeers pulls every tcp://, tls:// or quic:// peer URI out of
+// content (typically a public_peers country Markdown file, but any text
+// containing such URIs works) and tags the results with region/country.
+func extractPeers(content string, region string, country string) []Peer {
+	peers := []Peer{}
+	matches := PEER_REGEX.FindAllStringSubmatch(content, -1)
+	for _, match := range matches {
+		uri := match[0]
+		protocol := match[1]
+		host := match[2]
+		port, _ := strconv.Atoi(match[3])
+		peers = append(peers, Peer{
+			URI:      uri,
+			Protocol: protocol,
+			host:     host,
+			port:     port,
+			Region:   region,
+			Country:  country,
+		})
 	}
+	return peers
+}
 
-	ips, err := net.LookupIP(name)
+// lookupIPFunc matches net.LookupIP's signature so tests can inject a fake
+// resolver without touching the network.
+type lookupIPFunc func(host string) ([]net.IP, error)
+
+// resolve returns the best destination address for name, chosen by applying
+// RFC 6724 destination address selection (see addrselect.go) to the
+// candidates returned by lookup. Bracketed literals (e.g. "[::1]") are
+// returned with the brackets removed, without consulting lookup.
+func resolve(name string, lookup lookupIPFunc) (string, error) {
+	addrs, err := resolveAll(name, lookup)
 	if err != nil {
 		return "", err
 	}
-	return ips[0].String(), nil
+	return addrs[0], nil
 }
 
-func isUp(peer *Peer) {
-	addr, err := resolve(peer.host)
+// resolveAll returns every candidate address for name, sorted best-first per
+// RFC 6724, so callers can fall back to the next candidate if the first
+// fails to connect.
+func resolveAll(name string, lookup lookupIPFunc) ([]string, error) {
+	if strings.HasPrefix(name, "[") {
+		return []string{name[1 : len(name)-1]}, nil
+	}
+
+	ips, err := lookup(name)
 	if err != nil {
-		slog.Debug("Resolve error:", "msg", err, "type", fmt.Sprintf("%T", err))
-		return
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", name)
 	}
 
-	switch peer.protocol {
-	case "tcp", "tls":
-		startTime := time.Now()
-		// Dial the TCP/TLS server
-		conn, err := net.DialTimeout("tcp", "["+addr+"]:"+strconv.Itoa(peer.port), connTimeout)
-		if err != nil {
-			slog.Debug("Connection error:", "msg", err, "type", fmt.Sprintf("%T", err))
-			return
-		}
-		defer conn.Close()
-		peer.Latency = time.Since(startTime)
-		peer.Up = true
-	case "quic":
-		// Create a context
-		ctx := context.Background()
-
-		// Dial the QUIC server
-		startTime := time.Now()
-		conn, err := quic.DialAddr(ctx, "["+addr+"]:"+strconv.Itoa(peer.port), &tls.Config{InsecureSkipVerify: true}, nil)
-		if err != nil {
-			slog.Debug("Connection error:", "msg", err, "type", fmt.Sprintf("%T", err))
-			return
-		}
-		defer conn.CloseWithError(0, "Closing connection")
-		peer.Latency = time.Since(startTime)
-		peer.Up = true
+	sorted := sortDestinations(ips)
+	addrs := make([]string, len(sorted))
+	for i, ip := range sorted {
+		addrs[i] = ip.String()
 	}
+	return addrs, nil
 }
 
-func printResults(results []Peer) {
-	fmt.Println("Dead peers:")
-	deadTable := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
-	fmt.Fprintln(deadTable, "URI\tLocation")
+// Supported values for the -format flag.
+const (
+	formatTable      = "table"
+	formatJSON       = "json"
+	formatCSV        = "csv"
+	formatPrometheus = "prometheus"
+)
+
+func printResults(w io.Writer, results []Peer, format string) error {
+	switch format {
+	case formatJSON:
+		return printJSON(w, results)
+	case formatCSV:
+		return printCSV(w, results)
+	case formatPrometheus:
+		return printPrometheus(w, results)
+	case formatTable, "":
+		printTable(w, results)
+		return nil
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func printTable(w io.Writer, results []Peer) {
+	fmt.Fprintln(w, "Dead peers:")
+	deadTable := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(deadTable, "URI\tLocation\tReason")
 	for _, p := range results {
 		if !p.Up {
-			fmt.Fprintf(deadTable, "%s\t%s/%s\n", p.URI, p.Region, p.Country)
+			fmt.Fprintf(deadTable, "%s\t%s/%s\t%s\n", p.URI, p.Region, p.Country, p.Failure)
 		}
 	}
 	deadTable.Flush()
 
-	fmt.Println("\n\nAlive peers (sorted by latency):")
-	aliveTable := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
-	fmt.Fprintln(aliveTable, "URI\tLatency (ms)\tLocation")
+	fmt.Fprintln(w, "\n\nAlive peers (sorted by latency):")
+	aliveTable := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(aliveTable, "URI\tLatency (ms)\tLocation\tNode version")
 	alivePeers := []Peer{}
 	for _, p := range results {
 		if p.Up {
@@ -171,38 +208,147 @@ func printResults(results []Peer) {
 	})
 	for _, p := range alivePeers {
 		latency := p.Latency.Seconds() * 1000
-		fmt.Fprintf(aliveTable, "%s\t%.3f\t%s/%s\n", p.URI, latency, p.Region, p.Country)
+		fmt.Fprintf(aliveTable, "%s\t%.3f\t%s/%s\t%s\n", p.URI, latency, p.Region, p.Country, p.NodeVersion)
 	}
 	aliveTable.Flush()
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		fmt.Printf("Usage: %s [path to public_peers repository on a disk]\n", os.Args[0])
-		fmt.Printf("I.e.:  %s ~/Projects/yggdrasil/public_peers\n", os.Args[0])
-		return
+// printJSON emits the full result set as a JSON array of Peer objects.
+func printJSON(w io.Writer, results []Peer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// printCSV emits one row per peer: uri,protocol,region,country,up,latency_seconds,
+// failure_reason,failure_detail,node_version,node_key.
+func printCSV(w io.Writer, results []Peer) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	if err := cw.Write([]string{"uri", "protocol", "region", "country", "up", "latency_seconds", "failure_reason", "failure_detail", "node_version", "node_key"}); err != nil {
+		return err
+	}
+	for _, p := range results {
+		row := []string{
+			p.URI,
+			p.Protocol,
+			p.Region,
+			p.Country,
+			strconv.FormatBool(p.Up),
+			strconv.FormatFloat(p.Latency.Seconds(), 'f', -1, 64),
+			string(p.Failure),
+			p.FailureMsg,
+			p.NodeVersion,
+			p.NodeKey,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
 	}
+	return cw.Error()
+}
 
-	dataDir := os.Args[1]
+// printPrometheus emits peer_up and peer_latency_seconds gauges in the
+// Prometheus text exposition format, suitable for node_exporter's textfile
+// collector.
+func printPrometheus(w io.Writer, results []Peer) error {
+	fmt.Fprintln(w, "# HELP peer_up Whether the peer answered the liveness probe (1) or not (0).")
+	fmt.Fprintln(w, "# TYPE peer_up gauge")
+	for _, p := range results {
+		up := 0
+		if p.Up {
+			up = 1
+		}
+		fmt.Fprintf(w, "peer_up{uri=%q,region=%q,country=%q,protocol=%q} %d\n", p.URI, p.Region, p.Country, p.Protocol, up)
+	}
 
-	peers, err := getPeers(dataDir, nil, nil)
-	if err != nil {
-		fmt.Printf("Can't find peers in a directory: %s\n", dataDir)
-		return
+	fmt.Fprintln(w, "# HELP peer_latency_seconds Latency of the last successful probe, in seconds.")
+	fmt.Fprintln(w, "# TYPE peer_latency_seconds gauge")
+	for _, p := range results {
+		if !p.Up {
+			continue
+		}
+		fmt.Fprintf(w, "peer_latency_seconds{uri=%q,region=%q,country=%q,protocol=%q} %f\n", p.URI, p.Region, p.Country, p.Protocol, p.Latency.Seconds())
 	}
 
-	fmt.Println("Report date:", time.Now().Format(time.RFC1123))
+	fmt.Fprintln(w, "# HELP peer_last_failure Classification of the most recent failed probe (always 1 for dead peers).")
+	fmt.Fprintln(w, "# TYPE peer_last_failure gauge")
+	for _, p := range results {
+		if p.Up || p.Failure == FailureNone {
+			continue
+		}
+		fmt.Fprintf(w, "peer_last_failure{uri=%q,region=%q,country=%q,protocol=%q,reason=%q} 1\n", p.URI, p.Region, p.Country, p.Protocol, p.Failure)
+	}
+	return nil
+}
 
-	var wg sync.WaitGroup
+func newCheckerFromFlags(source PeerSource, parallel int, perHostDelay time.Duration, retries int, backoff time.Duration, deep bool) *Checker {
+	checker := NewChecker(source, nil, nil)
+	checker.Parallelism = parallel
+	checker.PerHostDelay = perHostDelay
+	checker.Retries = retries
+	checker.Backoff = backoff
+	checker.Deep = deep
+	return checker
+}
 
-	for i := range peers {
-		wg.Add(1)
-		go func(p *Peer) {
-			defer wg.Done()
-			isUp(p)
-		}(&peers[i])
+func runOnce(checker *Checker, format string) error {
+	if err := checker.Probe(); err != nil {
+		return err
 	}
 
-	wg.Wait()
-	printResults(peers)
+	if format == formatTable {
+		fmt.Println("Report date:", time.Now().Format(time.RFC1123))
+	}
+	return printResults(os.Stdout, checker.Results(), format)
+}
+
+func main() {
+	format := flag.String("format", formatTable, "output format: table, json, csv, or prometheus")
+	serve := flag.String("serve", "", "if set, run as a daemon and serve results on this address (e.g. :8080) instead of a one-shot check")
+	interval := flag.Duration("interval", 5*time.Minute, "re-probe interval in daemon mode (-serve)")
+	parallel := flag.Int("parallel", defaultParallelism, "maximum number of peers to dial concurrently")
+	perHostDelay := flag.Duration("host-delay", 0, "minimum delay between dials to the same resolved IP (0 disables)")
+	retries := flag.Int("retries", 0, "number of retries for an address that fails to connect")
+	backoff := flag.Duration("backoff", 500*time.Millisecond, "base delay between retries, doubled after each failed attempt")
+	source := flag.String("source", "", "peer source: file:///path, git+https://host/repo.git, https://host/peers.json, or - for stdin (overrides the positional path argument)")
+	deep := flag.Bool("deep", false, "require a recognized metadata handshake reply (not just a dial) before a peer counts as up, and record its reported version/key")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [path to public_peers repository on a disk]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "I.e.:  %s ~/Projects/yggdrasil/public_peers\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var src PeerSource
+	if *source != "" {
+		var err error
+		src, err = parseSource(*source)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	} else {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			return
+		}
+		src = fileSource{DataDir: flag.Arg(0)}
+	}
+
+	checker := newCheckerFromFlags(src, *parallel, *perHostDelay, *retries, *backoff, *deep)
+
+	if *serve != "" {
+		if err := runServer(*serve, checker, *interval); err != nil {
+			fmt.Fprintln(os.Stderr, "Server error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := runOnce(checker, *format); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// FailureReason classifies why a probe failed, so dead peers can report
+// something more useful than a bare "dead".
+type FailureReason string
+
+const (
+	FailureNone              FailureReason = ""
+	FailureDNS               FailureReason = "dns_error"
+	FailureTimeout           FailureReason = "timeout"
+	FailureConnectionRefused FailureReason = "connection_refused"
+	FailureTLSHandshake      FailureReason = "tls_handshake_failed"
+	FailureQUICVersion       FailureReason = "quic_version_negotiation_failed"
+	// FailureDeepUnverified means a -deep probe connected at the transport
+	// level but the peer didn't reply with a recognized metadata handshake
+	// (see deepprobe.go) -- e.g. a middlebox terminating TLS/QUIC without
+	// speaking Yggdrasil on top. This is reported distinctly from a plain
+	// dial failure since the transport connection itself did succeed.
+	FailureDeepUnverified FailureReason = "deep_handshake_unrecognized"
+	FailureOther          FailureReason = "other"
+)
+
+// RetryConfig controls how many times a dead address is retried, how long to
+// wait between attempts, and whether probes go beyond a bare dial.
+type RetryConfig struct {
+	Retries int           // additional attempts after the first failure
+	Backoff time.Duration // base delay; doubles after each failed attempt
+	// Deep, if set, requires a recognized metadata handshake (see
+	// deepprobe.go) after connecting, not just the transport-level
+	// connection, before the peer counts as up; a peer that connects but
+	// doesn't reply in kind is reported as down with FailureDeepUnverified.
+	Deep bool
+}
+
+// classifyError maps a dial error to a FailureReason.
+func classifyError(err error, protocol string) FailureReason {
+	if err == nil {
+		return FailureNone
+	}
+
+	if errors.Is(err, errUnrecognizedReply) {
+		return FailureDeepUnverified
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return FailureTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), "connection refused") {
+		return FailureConnectionRefused
+	}
+
+	if protocol == "tls" {
+		var recordHeaderErr tls.RecordHeaderError
+		if errors.As(err, &recordHeaderErr) || strings.Contains(err.Error(), "tls:") {
+			return FailureTLSHandshake
+		}
+	}
+
+	if protocol == "quic" {
+		var versionErr *quic.VersionNegotiationError
+		if errors.As(err, &versionErr) {
+			return FailureQUICVersion
+		}
+	}
+
+	return FailureOther
+}
+
+// dialFn performs a single dial attempt; probeAddr calls it once per retry
+// attempt. It's a package var (rather than a direct call to attemptDial) so
+// tests can fake dial failures/successes to exercise the retry/backoff loop
+// without touching the network.
+var dialFn = attemptDial
+
+// attemptDial performs a single connection attempt to addr without mutating
+// peer beyond what's needed to pick a protocol, returning the latency of a
+// successful connection. If deep is set, a connection alone isn't enough: it
+// also requires a recognized reply to the metadata exchange in deepprobe.go
+// (recording the reported node version/key on peer), so a peer that accepts
+// the transport connection but doesn't speak Yggdrasil on top is reported as
+// a failed attempt (classified FailureDeepUnverified), not a live peer.
+func attemptDial(peer *Peer, addr string, deep bool) (time.Duration, error) {
+	switch peer.Protocol {
+	case "tcp", "tls":
+		startTime := time.Now()
+		conn, err := net.DialTimeout("tcp", "["+addr+"]:"+strconv.Itoa(peer.port), connTimeout)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		if deep {
+			if err := deepHandshake(peer, conn); err != nil {
+				return 0, err
+			}
+		}
+		return time.Since(startTime), nil
+	case "quic":
+		ctx, cancel := context.WithTimeout(context.Background(), connTimeout)
+		defer cancel()
+		startTime := time.Now()
+		conn, err := quic.DialAddr(ctx, "["+addr+"]:"+strconv.Itoa(peer.port), &tls.Config{InsecureSkipVerify: true}, nil)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.CloseWithError(0, "Closing connection")
+		if deep {
+			stream, err := conn.OpenStreamSync(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("%w: opening metadata stream: %w", errUnrecognizedReply, err)
+			}
+			defer stream.Close()
+			if err := deepHandshake(peer, stream); err != nil {
+				return 0, err
+			}
+		}
+		return time.Since(startTime), nil
+	}
+	return 0, fmt.Errorf("unsupported protocol: %s", peer.Protocol)
+}
+
+// probeAddr dials addr, retrying on failure per cfg with exponential
+// backoff, and records the outcome (the successful attempt's latency, or a
+// classified failure reason) on peer. It reports whether the peer ended up
+// reachable.
+func probeAddr(peer *Peer, addr string, cfg RetryConfig) bool {
+	var lastErr error
+
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(cfg.Backoff * time.Duration(int64(1)<<uint(attempt-1)))
+		}
+		peer.Attempts++
+		lat, err := dialFn(peer, addr, cfg.Deep)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		peer.Up = true
+		peer.Failure = FailureNone
+		peer.FailureMsg = ""
+		peer.Latency = lat
+		return true
+	}
+
+	peer.Failure = classifyError(lastErr, peer.Protocol)
+	if lastErr != nil {
+		peer.FailureMsg = lastErr.Error()
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		protocol string
+		want     FailureReason
+	}{
+		{"nil error", nil, "tcp", FailureNone},
+		{"timeout", &net.DNSError{IsTimeout: true}, "tcp", FailureTimeout},
+		{"connection refused", errors.New("dial tcp 1.2.3.4:443: connect: connection refused"), "tcp", FailureConnectionRefused},
+		{"tls handshake", errors.New("tls: handshake failure"), "tls", FailureTLSHandshake},
+		{"unclassified", errors.New("something unexpected"), "tcp", FailureOther},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := classifyError(c.err, c.protocol); got != c.want {
+				t.Errorf("classifyError(%v, %q) = %q, want %q", c.err, c.protocol, got, c.want)
+			}
+		})
+	}
+}
+
+// TestAttemptDialDeepFailsOnUnrecognizedPeer simulates a peer that accepts
+// the TCP connection but doesn't speak our metadata handshake at all -- the
+// middlebox-terminates-TLS/QUIC case -deep exists to catch. In -deep mode, a
+// transport-level connection alone must not count as a live peer.
+func TestAttemptDialDeepFailsOnUnrecognizedPeer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("not a metadata handshake reply"))
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	peer := &Peer{Protocol: "tcp", port: addr.Port}
+
+	_, err = attemptDial(peer, addr.IP.String(), true)
+	if err == nil {
+		t.Fatal("attemptDial with an unrecognized deep reply returned no error, want it to fail the attempt")
+	}
+	if got := classifyError(err, peer.Protocol); got != FailureDeepUnverified {
+		t.Errorf("classifyError(...) = %q, want %q", got, FailureDeepUnverified)
+	}
+	if peer.NodeVersion != "" || peer.NodeKey != "" {
+		t.Errorf("NodeVersion/NodeKey = %q/%q, want both empty since the peer didn't reply in our handshake format", peer.NodeVersion, peer.NodeKey)
+	}
+}
+
+// TestAttemptDialDeepSucceedsOnRecognizedReply is the positive case: a peer
+// that replies in our metadata handshake format is reported reachable, with
+// the version/key it announced recorded on Peer.
+func TestAttemptDialDeepSucceedsOnRecognizedReply(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(append([]byte(metaMagic), 0, 4, 0, 1, 0))
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	peer := &Peer{Protocol: "tcp", port: addr.Port}
+
+	if _, err := attemptDial(peer, addr.IP.String(), true); err != nil {
+		t.Fatalf("attemptDial with a recognized deep reply returned an error: %v", err)
+	}
+	if peer.NodeVersion != "4.1" {
+		t.Errorf("NodeVersion = %q, want %q", peer.NodeVersion, "4.1")
+	}
+}
+
+func TestProbeAddrRetriesThenSucceeds(t *testing.T) {
+	orig := dialFn
+	defer func() { dialFn = orig }()
+
+	var calls int
+	dialFn = func(peer *Peer, addr string, deep bool) (time.Duration, error) {
+		calls++
+		if calls <= 2 {
+			return 0, errors.New("dial tcp 1.2.3.4:443: connect: connection refused")
+		}
+		return 42 * time.Millisecond, nil
+	}
+
+	peer := &Peer{Protocol: "tcp"}
+	cfg := RetryConfig{Retries: 2, Backoff: 5 * time.Millisecond}
+
+	start := time.Now()
+	ok := probeAddr(peer, "1.2.3.4", cfg)
+	elapsed := time.Since(start)
+
+	if !ok {
+		t.Fatal("probeAddr = false, want true (third attempt succeeds)")
+	}
+	if peer.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", peer.Attempts)
+	}
+	if !peer.Up || peer.Failure != FailureNone {
+		t.Errorf("Up = %v, Failure = %q, want Up = true, Failure = FailureNone", peer.Up, peer.Failure)
+	}
+	if peer.Latency != 42*time.Millisecond {
+		t.Errorf("Latency = %v, want 42ms", peer.Latency)
+	}
+	// Backoff doubles after each failed attempt: 5ms then 10ms before the
+	// third (successful) try.
+	if wantMin := 15 * time.Millisecond; elapsed < wantMin {
+		t.Errorf("elapsed = %v, want at least %v (5ms + 10ms backoff)", elapsed, wantMin)
+	}
+}
+
+func TestProbeAddrExhaustsRetriesAndClassifiesFailure(t *testing.T) {
+	orig := dialFn
+	defer func() { dialFn = orig }()
+
+	dialFn = func(peer *Peer, addr string, deep bool) (time.Duration, error) {
+		return 0, errors.New("dial tcp 1.2.3.4:443: connect: connection refused")
+	}
+
+	peer := &Peer{Protocol: "tcp"}
+	cfg := RetryConfig{Retries: 2, Backoff: time.Millisecond}
+
+	if probeAddr(peer, "1.2.3.4", cfg) {
+		t.Fatal("probeAddr = true, want false (every attempt fails)")
+	}
+	if peer.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3 (1 initial + 2 retries)", peer.Attempts)
+	}
+	if peer.Up {
+		t.Error("Up = true, want false")
+	}
+	if peer.Failure != FailureConnectionRefused {
+		t.Errorf("Failure = %q, want %q", peer.Failure, FailureConnectionRefused)
+	}
+	if peer.FailureMsg == "" {
+		t.Error("FailureMsg is empty, want the last dial error's message")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// hostLimiter spaces out dials to the same resolved address so that peers
+// sharing a host (e.g. several ports on one VPS) aren't all dialed in the
+// same instant. It behaves like a per-key token bucket with a bucket size
+// of one: each key may be used at most once per delay.
+type hostLimiter struct {
+	delay time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{
+		delay: delay,
+		next:  make(map[string]time.Time),
+	}
+}
+
+// wait blocks, if necessary, until addr is allowed to be dialed again.
+func (h *hostLimiter) wait(addr string) {
+	if h.delay <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	scheduled := h.next[addr]
+	if scheduled.Before(now) {
+		scheduled = now
+	}
+	h.next[addr] = scheduled.Add(h.delay)
+	h.mu.Unlock()
+
+	if wait := scheduled.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// probeFn performs the resolve-then-dial work for a single peer; Checker.Probe
+// calls it once per peer from inside its worker pool. It's a package var
+// (rather than a direct call to probeWithLimiter) so tests can swap in a
+// fake to exercise Probe's concurrency control without touching the network.
+var probeFn = probeWithLimiter
+
+// probeWithLimiter resolves peer's host, then probes its RFC 6724-ordered
+// candidate addresses in turn (retrying each per cfg), waiting for the
+// per-host rate limit to clear before every dial and falling back to the
+// next candidate if all retries on one address are exhausted.
+func probeWithLimiter(peer *Peer, limiter *hostLimiter, cfg RetryConfig) {
+	addrs, err := resolveAll(peer.host, net.LookupIP)
+	if err != nil {
+		peer.Failure = FailureDNS
+		peer.FailureMsg = err.Error()
+		return
+	}
+
+	for _, addr := range addrs {
+		limiter.wait(addr)
+		if probeAddr(peer, addr, cfg) {
+			return
+		}
+	}
+}
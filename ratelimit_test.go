@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostLimiterSpacesOutSameHostDials(t *testing.T) {
+	delay := 30 * time.Millisecond
+	limiter := newHostLimiter(delay)
+
+	start := time.Now()
+	limiter.wait("203.0.113.1")
+	limiter.wait("203.0.113.1")
+	elapsed := time.Since(start)
+
+	if elapsed < delay {
+		t.Errorf("two waits on the same addr took %s, want at least %s", elapsed, delay)
+	}
+}
+
+func TestHostLimiterDoesNotSpaceOutDifferentHosts(t *testing.T) {
+	limiter := newHostLimiter(time.Hour)
+
+	done := make(chan struct{})
+	go func() {
+		limiter.wait("203.0.113.1")
+		limiter.wait("203.0.113.2")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("wait on a different addr blocked; hostLimiter should key by address")
+	}
+}
+
+func TestHostLimiterDisabledWithZeroDelay(t *testing.T) {
+	limiter := newHostLimiter(0)
+
+	done := make(chan struct{})
+	go func() {
+		limiter.wait("203.0.113.1")
+		limiter.wait("203.0.113.1")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("wait blocked with delay disabled (zero PerHostDelay)")
+	}
+}
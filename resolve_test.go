@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	// Test case 1: Valid name
+	name := "example.com"
+	expectedIP := []net.IP{net.ParseIP("93.184.216.34")}
+	resolver := func(name string) ([]net.IP, error) {
+		return expectedIP, nil
+	}
+
+	ip, err := resolve(name, resolver)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if ip != expectedIP[0].String() {
+		t.Errorf("Expected IP: %s, but got: %s", expectedIP, ip)
+	}
+
+	// Test case 2: Resolver error
+	expectedErr := "Resolver error"
+	resolver = func(name string) ([]net.IP, error) {
+		return nil, errors.New(expectedErr)
+	}
+
+	_, err = resolve(name, resolver)
+	if err == nil {
+		t.Errorf("Expected error, but got nil")
+	}
+
+	if err.Error() != expectedErr {
+		t.Errorf("Expected error: %s, but got: %v", expectedErr, err)
+	}
+
+	// Test case 3: IPv6 address
+	expectedIP = []net.IP{net.ParseIP("2001:4860:4860::8888")}
+	resolver = func(name string) ([]net.IP, error) {
+		return nil, nil
+	}
+	ip, err = resolve("[2001:4860:4860::8888]", resolver)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if ip != expectedIP[0].String() {
+		t.Errorf("Expected IP: %s, but got: %s", expectedIP, ip)
+	}
+}
+
+// TestResolveAllOrdersByRFC6724Rules drives resolveAll with a fake
+// multi-candidate resolver and asserts the RFC 6724 order sortDestinations
+// produces. The expected orders hold only on a host with a default IPv4 route
+// whose source address is public (global scope), so that srcAddrFor finds a
+// source address for both IPv4 destinations and only 8.8.8.8 matches its
+// scope, and with no real IPv6 connectivity.
+func TestResolveAllOrdersByRFC6724Rules(t *testing.T) {
+	cases := []struct {
+		name string
+		ips  []net.IP
+		want []string
+	}{
+		{
+			name: "prefers a destination whose scope matches our source over a private one",
+			ips:  []net.IP{net.ParseIP("192.168.1.1"), net.ParseIP("8.8.8.8")},
+			want: []string{"8.8.8.8", "192.168.1.1"},
+		},
+		{
+			name: "prefers a reachable IPv4 candidate over an unreachable IPv6 one",
+			ips:  []net.IP{net.ParseIP("2001:4860:4860::8888"), net.ParseIP("8.8.8.8")},
+			want: []string{"8.8.8.8", "2001:4860:4860::8888"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lookup := func(name string) ([]net.IP, error) { return c.ips, nil }
+
+			got, err := resolveAll("public_peers_test_host", lookup)
+			if err != nil {
+				t.Fatalf("resolveAll error: %v", err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("resolveAll(%v) = %v, want %v", c.ips, got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Fatalf("resolveAll(%v) = %v, want %v", c.ips, got, c.want)
+				}
+			}
+
+			best, err := resolve("public_peers_test_host", lookup)
+			if err != nil {
+				t.Fatalf("resolve error: %v", err)
+			}
+			if best != c.want[0] {
+				t.Errorf("resolve(%v) = %s, want %s", c.ips, best, c.want[0])
+			}
+		})
+	}
+}
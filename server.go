@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// runServer probes once synchronously, then serves the cached results on
+// addr, re-probing every interval until the process is killed.
+func runServer(addr string, checker *Checker, interval time.Duration) error {
+	if err := checker.Probe(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := checker.Probe(); err != nil {
+				slog.Error("Probe error", "msg", err)
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler(checker))
+	mux.HandleFunc("/peers.json", peersJSONHandler(checker))
+	mux.HandleFunc("/metrics", metricsHandler(checker))
+
+	slog.Info("Serving peer status", "addr", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func healthzHandler(checker *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if checker.LastChecked().IsZero() {
+			http.Error(w, "no probe completed yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "ok, last checked %s\n", checker.LastChecked().Format(time.RFC3339))
+	}
+}
+
+func peersJSONHandler(checker *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(checker.Results()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func metricsHandler(checker *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := printPrometheus(w, checker.Results()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
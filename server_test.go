@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	checker := NewChecker(fakeSource{}, nil, nil)
+
+	rec := httptest.NewRecorder()
+	healthzHandler(checker)(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before first probe: status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	if err := checker.Probe(); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	healthzHandler(checker)(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after first probe: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestPeersJSONHandler(t *testing.T) {
+	peers := []Peer{{URI: "tcp://example.com:1234", Protocol: "tcp", Region: "region", Country: "country.md"}}
+	checker := NewChecker(fakeSource{peers: peers}, nil, nil)
+	if err := checker.Probe(); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	peersJSONHandler(checker)(rec, httptest.NewRequest(http.MethodGet, "/peers.json", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var got []Peer
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshalling response: %v", err)
+	}
+	if len(got) != 1 || got[0].URI != peers[0].URI || got[0].Protocol != peers[0].Protocol {
+		t.Errorf("peers.json = %+v, want it to carry the probed peer", got)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	peers := []Peer{{URI: "tcp://example.com:1234", Protocol: "tcp"}}
+	checker := NewChecker(fakeSource{peers: peers}, nil, nil)
+	if err := checker.Probe(); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	metricsHandler(checker)(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.Contains(rec.Body.String(), "peer_up{") {
+		t.Errorf("/metrics body missing peer_up gauge:\n%s", rec.Body.String())
+	}
+}
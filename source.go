@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sourceFetchTimeout bounds how long a single git clone/pull or HTTP(S)
+// fetch may take, so a stalled remote doesn't wedge the daemon's re-probe
+// loop (runServer calls Checker.Probe, and thus PeerSource.Peers,
+// synchronously from a single ticker goroutine).
+const sourceFetchTimeout = 30 * time.Second
+
+// PeerSource produces the set of peers to check. fileSource and gitSource
+// restrict it to regions and countries when given (nil/empty means "all");
+// httpSource and stdinSource ignore both filters and return every peer they
+// find. getPeers's local directory walk is one implementation among several.
+type PeerSource interface {
+	Peers(regions []string, countries []string) ([]Peer, error)
+}
+
+// fileSource reads peers from a public_peers checkout already present on
+// disk, using the original directory-walking logic.
+type fileSource struct {
+	DataDir string
+}
+
+func (s fileSource) Peers(regions []string, countries []string) ([]Peer, error) {
+	return getPeers(s.DataDir, regions, countries)
+}
+
+// gitSource clones (or, on subsequent runs, pulls) a public_peers-shaped git
+// repository into a local cache directory, then reads it like fileSource.
+type gitSource struct {
+	URL string
+}
+
+func (s gitSource) cacheDir() string {
+	sum := sha1.Sum([]byte(s.URL))
+	return filepath.Join(os.TempDir(), "peer_checker-cache", hex.EncodeToString(sum[:]))
+}
+
+func (s gitSource) sync() (string, error) {
+	dir := s.cacheDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sourceFetchTimeout)
+	defer cancel()
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		cmd := exec.CommandContext(ctx, "git", "-C", dir, "pull", "--ff-only")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git pull %s: %w: %s", s.URL, err, out)
+		}
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", s.URL, dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git clone %s: %w: %s", s.URL, err, out)
+	}
+	return dir, nil
+}
+
+func (s gitSource) Peers(regions []string, countries []string) ([]Peer, error) {
+	dir, err := s.sync()
+	if err != nil {
+		return nil, err
+	}
+	return getPeers(dir, regions, countries)
+}
+
+// httpSource fetches a single document over HTTP(S) and extracts peers from
+// it, either as a JSON array of peer URI strings or (the default) by
+// regex-scanning it the same way a public_peers Markdown file is scanned.
+type httpSource struct {
+	URL string
+}
+
+func (s httpSource) Peers(regions []string, countries []string) ([]Peer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sourceFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", s.URL, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") || strings.HasSuffix(s.URL, ".json") {
+		var uris []string
+		if err := json.Unmarshal(body, &uris); err != nil {
+			return nil, fmt.Errorf("parsing %s as a JSON peer list: %w", s.URL, err)
+		}
+		return extractPeers(strings.Join(uris, "\n"), "", ""), nil
+	}
+
+	return extractPeers(string(body), "", ""), nil
+}
+
+// stdinSource reads a newline-separated list of peer URIs (e.g.
+// "tcp://host:port") from stdin, one per line.
+type stdinSource struct{}
+
+func (stdinSource) Peers(regions []string, countries []string) ([]Peer, error) {
+	content, err := io.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return nil, err
+	}
+	return extractPeers(string(content), "", ""), nil
+}
+
+// parseSource builds a PeerSource from a -source flag value:
+//
+//	file:///path/to/public_peers   -- local checkout (also the default for a bare path)
+//	git+https://host/repo.git      -- cloned/pulled into a local cache on demand
+//	https://host/peers.json         -- fetched fresh on every Peers() call
+//	-                               -- read from stdin
+func parseSource(source string) (PeerSource, error) {
+	switch {
+	case source == "-":
+		return stdinSource{}, nil
+	case strings.HasPrefix(source, "file://"):
+		return fileSource{DataDir: strings.TrimPrefix(source, "file://")}, nil
+	case strings.HasPrefix(source, "git+"):
+		return gitSource{URL: strings.TrimPrefix(source, "git+")}, nil
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		return httpSource{URL: source}, nil
+	default:
+		return fileSource{DataDir: source}, nil
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseSource(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   PeerSource
+	}{
+		{"bare path", "/tmp/public_peers", fileSource{DataDir: "/tmp/public_peers"}},
+		{"file scheme", "file:///tmp/public_peers", fileSource{DataDir: "/tmp/public_peers"}},
+		{"git scheme", "git+https://example.com/public_peers.git", gitSource{URL: "https://example.com/public_peers.git"}},
+		{"https scheme", "https://example.com/peers.json", httpSource{URL: "https://example.com/peers.json"}},
+		{"stdin", "-", stdinSource{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseSource(c.source)
+			if err != nil {
+				t.Fatalf("parseSource(%q) returned error: %v", c.source, err)
+			}
+			if got != c.want {
+				t.Errorf("parseSource(%q) = %#v, want %#v", c.source, got, c.want)
+			}
+		})
+	}
+}